@@ -0,0 +1,244 @@
+package bier
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Env vars
+var redisURL = os.Getenv("REDIS_URL")
+
+// defaultCacheTTL is how long a provider response is cached before it's
+// considered stale enough to re-fetch from the upstream API.
+const defaultCacheTTL = 1 * time.Hour
+
+// memoryCacheSize bounds the in-memory LRU fallback so a long-lived instance
+// doesn't grow unbounded when Redis isn't configured.
+const memoryCacheSize = 500
+
+// Cache stores provider results so repeated /hi5 invocations for the same
+// search don't have to spend the upstream API's daily quota.
+type Cache interface {
+	Get(ctx context.Context, key string) ([]Business, bool, error)
+	Set(ctx context.Context, key string, businesses []Business, ttl time.Duration) error
+}
+
+// cacheKey identifies a cached search by everything that affects its result.
+func cacheKey(provider, category, location string, radius int, term string) string {
+	return fmt.Sprintf("hi5:%s:%s:%s:%d:%s", provider, category, location, radius, term)
+}
+
+// newCache builds a RedisCache when REDIS_URL is set, falling back to an
+// in-memory LRU cache otherwise so the function still works in dev or when
+// Redis is unavailable.
+func newCache() Cache {
+	if redisURL != "" {
+		opt, err := redis.ParseURL(redisURL)
+		if err != nil {
+			log.Printf("Invalid REDIS_URL, falling back to in-memory cache: %s", err.Error())
+			return newMemoryCache(memoryCacheSize)
+		}
+		return &RedisCache{client: redis.NewClient(opt)}
+	}
+	return newMemoryCache(memoryCacheSize)
+}
+
+// RedisCache stores results in Redis, keyed on (provider, category, location,
+// radius, term).
+type RedisCache struct {
+	client *redis.Client
+}
+
+func (c *RedisCache) Get(ctx context.Context, key string) ([]Business, bool, error) {
+	data, err := c.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var businesses []Business
+	if err := json.Unmarshal(data, &businesses); err != nil {
+		return nil, false, err
+	}
+	return businesses, true, nil
+}
+
+func (c *RedisCache) Set(ctx context.Context, key string, businesses []Business, ttl time.Duration) error {
+	data, err := json.Marshal(businesses)
+	if err != nil {
+		return err
+	}
+	return c.client.Set(ctx, key, data, ttl).Err()
+}
+
+// memoryCacheEntry pairs a cached value with its expiry.
+type memoryCacheEntry struct {
+	key        string
+	businesses []Business
+	expiresAt  time.Time
+}
+
+// MemoryCache is a size-bounded, TTL-aware LRU used when Redis isn't
+// configured.
+type MemoryCache struct {
+	mu       sync.Mutex
+	size     int
+	order    *list.List
+	elements map[string]*list.Element
+}
+
+func newMemoryCache(size int) *MemoryCache {
+	return &MemoryCache{
+		size:     size,
+		order:    list.New(),
+		elements: make(map[string]*list.Element),
+	}
+}
+
+func (c *MemoryCache) Get(ctx context.Context, key string) ([]Business, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.elements[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := el.Value.(*memoryCacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.order.Remove(el)
+		delete(c.elements, key)
+		return nil, false, nil
+	}
+	c.order.MoveToFront(el)
+	return entry.businesses, true, nil
+}
+
+func (c *MemoryCache) Set(ctx context.Context, key string, businesses []Business, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.elements[key]; ok {
+		c.order.Remove(el)
+	}
+	el := c.order.PushFront(&memoryCacheEntry{
+		key:        key,
+		businesses: businesses,
+		expiresAt:  time.Now().Add(ttl),
+	})
+	c.elements[key] = el
+
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		if oldest == nil {
+			break
+		}
+		c.order.Remove(oldest)
+		delete(c.elements, oldest.Value.(*memoryCacheEntry).key)
+	}
+	return nil
+}
+
+// Limiter caps how often a given Slack user can invoke /hi5.
+type Limiter interface {
+	Allow(ctx context.Context, userID string) (bool, error)
+}
+
+// defaultRateLimit is how many requests a user gets per rateLimitWindow.
+var defaultRateLimit = envInt("RATE_LIMIT_PER_HOUR", 10)
+
+const rateLimitWindow = 1 * time.Hour
+
+// rateLimiterSize bounds TokenBucketLimiter's user table the same way
+// memoryCacheSize bounds MemoryCache, so a long-lived instance doesn't grow
+// unbounded as distinct users hit /hi5.
+const rateLimiterSize = 5000
+
+func envInt(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return fallback
+}
+
+// tokenBucket tracks one user's remaining requests for the current window.
+type tokenBucket struct {
+	userID    string
+	remaining int
+	resetAt   time.Time
+}
+
+// TokenBucketLimiter is an in-memory, per-user_id rate limiter. Each user's
+// window starts at their first request rather than on a calendar hour, and
+// the full quota comes back once that window has expired; requests at the
+// end of one window and the start of the next are counted against separate
+// quotas. Bucket eviction is LRU-bounded
+// like MemoryCache, so a workspace with many distinct users can't grow this
+// table unbounded.
+type TokenBucketLimiter struct {
+	mu       sync.Mutex
+	limit    int
+	window   time.Duration
+	size     int
+	order    *list.List
+	elements map[string]*list.Element
+}
+
+func newLimiter() *TokenBucketLimiter {
+	return &TokenBucketLimiter{
+		limit:    defaultRateLimit,
+		window:   rateLimitWindow,
+		size:     rateLimiterSize,
+		order:    list.New(),
+		elements: make(map[string]*list.Element),
+	}
+}
+
+func (l *TokenBucketLimiter) Allow(ctx context.Context, userID string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	el, ok := l.elements[userID]
+	var b *tokenBucket
+	if ok {
+		b = el.Value.(*tokenBucket)
+	}
+	if !ok || now.After(b.resetAt) {
+		b = &tokenBucket{userID: userID, remaining: l.limit, resetAt: now.Add(l.window)}
+		if ok {
+			el.Value = b
+			l.order.MoveToFront(el)
+		} else {
+			l.elements[userID] = l.order.PushFront(b)
+		}
+	} else {
+		l.order.MoveToFront(el)
+	}
+
+	for l.order.Len() > l.size {
+		oldest := l.order.Back()
+		if oldest == nil {
+			break
+		}
+		l.order.Remove(oldest)
+		delete(l.elements, oldest.Value.(*tokenBucket).userID)
+	}
+
+	if b.remaining <= 0 {
+		return false, nil
+	}
+	b.remaining--
+	return true, nil
+}
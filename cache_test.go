@@ -0,0 +1,102 @@
+package bier
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheGetSet(t *testing.T) {
+	c := newMemoryCache(2)
+	ctx := context.Background()
+
+	if _, hit, err := c.Get(ctx, "missing"); err != nil || hit {
+		t.Fatalf("Get(missing) = hit=%v err=%v, want hit=false err=nil", hit, err)
+	}
+
+	want := []Business{{Name: "Pizza Place"}}
+	if err := c.Set(ctx, "a", want, time.Hour); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	got, hit, err := c.Get(ctx, "a")
+	if err != nil || !hit {
+		t.Fatalf("Get(a) = hit=%v err=%v, want hit=true err=nil", hit, err)
+	}
+	if len(got) != 1 || got[0].Name != want[0].Name {
+		t.Fatalf("Get(a) = %+v, want %+v", got, want)
+	}
+}
+
+func TestMemoryCacheExpiry(t *testing.T) {
+	c := newMemoryCache(2)
+	ctx := context.Background()
+
+	if err := c.Set(ctx, "a", []Business{{Name: "stale"}}, -time.Minute); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	if _, hit, err := c.Get(ctx, "a"); err != nil || hit {
+		t.Fatalf("Get(a) after expiry = hit=%v err=%v, want hit=false err=nil", hit, err)
+	}
+}
+
+func TestMemoryCacheEvictsLRU(t *testing.T) {
+	c := newMemoryCache(2)
+	ctx := context.Background()
+
+	c.Set(ctx, "a", []Business{{Name: "a"}}, time.Hour)
+	c.Set(ctx, "b", []Business{{Name: "b"}}, time.Hour)
+	// Touch "a" so "b" becomes the least recently used entry.
+	c.Get(ctx, "a")
+	c.Set(ctx, "c", []Business{{Name: "c"}}, time.Hour)
+
+	if _, hit, _ := c.Get(ctx, "b"); hit {
+		t.Fatalf("Get(b) hit = true, want evicted")
+	}
+	if _, hit, _ := c.Get(ctx, "a"); !hit {
+		t.Fatalf("Get(a) hit = false, want still present")
+	}
+	if _, hit, _ := c.Get(ctx, "c"); !hit {
+		t.Fatalf("Get(c) hit = false, want present")
+	}
+}
+
+func TestTokenBucketLimiterAllow(t *testing.T) {
+	l := newLimiter()
+	l.limit = 2
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		allowed, err := l.Allow(ctx, "u1")
+		if err != nil || !allowed {
+			t.Fatalf("Allow(u1) call %d = allowed=%v err=%v, want allowed=true err=nil", i, allowed, err)
+		}
+	}
+
+	if allowed, err := l.Allow(ctx, "u1"); err != nil || allowed {
+		t.Fatalf("Allow(u1) after quota exhausted = allowed=%v err=%v, want allowed=false err=nil", allowed, err)
+	}
+
+	if allowed, err := l.Allow(ctx, "u2"); err != nil || !allowed {
+		t.Fatalf("Allow(u2) = allowed=%v err=%v, want allowed=true err=nil", allowed, err)
+	}
+}
+
+func TestTokenBucketLimiterEvictsLRU(t *testing.T) {
+	l := newLimiter()
+	l.limit = 1
+	l.size = 2
+	ctx := context.Background()
+
+	l.Allow(ctx, "a")
+	l.Allow(ctx, "b")
+	l.Allow(ctx, "c")
+
+	if l.order.Len() != 2 {
+		t.Fatalf("bucket table size = %d, want 2", l.order.Len())
+	}
+	if _, ok := l.elements["a"]; ok {
+		t.Fatalf("least recently used bucket %q was not evicted", "a")
+	}
+}
@@ -0,0 +1,97 @@
+package bier
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const subscriptionsCollection = "subscriptions"
+const ticksCollection = "subscription_ticks"
+
+// FirestoreStore persists subscriptions in Firestore, keyed by
+// team_id + channel_id + subscription_id.
+type FirestoreStore struct {
+	client *firestore.Client
+}
+
+func newFirestoreStore(projectID string) Store {
+	client, err := firestore.NewClient(context.Background(), projectID)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to create firestore client, falling back to in-memory store")
+		return newMemoryStore()
+	}
+	return &FirestoreStore{client: client}
+}
+
+func (s *FirestoreStore) docID(teamID, channelID, id string) string {
+	return fmt.Sprintf("%s_%s_%s", teamID, channelID, id)
+}
+
+func (s *FirestoreStore) Create(ctx context.Context, sub *Subscription) error {
+	_, err := s.client.Collection(subscriptionsCollection).
+		Doc(s.docID(sub.TeamID, sub.ChannelID, sub.ID)).
+		Set(ctx, sub)
+	return err
+}
+
+func (s *FirestoreStore) Delete(ctx context.Context, teamID, channelID, id string) error {
+	_, err := s.client.Collection(subscriptionsCollection).
+		Doc(s.docID(teamID, channelID, id)).
+		Delete(ctx)
+	return err
+}
+
+func (s *FirestoreStore) List(ctx context.Context, teamID, channelID string) ([]*Subscription, error) {
+	iter := s.client.Collection(subscriptionsCollection).
+		Where("TeamID", "==", teamID).
+		Where("ChannelID", "==", channelID).
+		Documents(ctx)
+	defer iter.Stop()
+	return collectSubscriptions(iter)
+}
+
+func (s *FirestoreStore) All(ctx context.Context) ([]*Subscription, error) {
+	iter := s.client.Collection(subscriptionsCollection).Documents(ctx)
+	defer iter.Stop()
+	return collectSubscriptions(iter)
+}
+
+func collectSubscriptions(iter *firestore.DocumentIterator) ([]*Subscription, error) {
+	var subs []*Subscription
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var sub Subscription
+		if err := doc.DataTo(&sub); err != nil {
+			return nil, err
+		}
+		subs = append(subs, &sub)
+	}
+	return subs, nil
+}
+
+// MarkRun uses a tick-keyed document as an idempotency marker: the first
+// caller to create it wins, later Cloud Scheduler retries for the same tick
+// fail the create and are treated as already-delivered.
+func (s *FirestoreStore) MarkRun(ctx context.Context, id string, tick time.Time) (bool, error) {
+	doc := s.client.Collection(ticksCollection).Doc(fmt.Sprintf("%s_%d", id, tick.Unix()))
+	_, err := doc.Create(ctx, map[string]interface{}{"ranAt": time.Now()})
+	if status.Code(err) == codes.AlreadyExists {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,23 @@
+package bier
+
+import (
+	"net/http"
+	"time"
+)
+
+// slackBudget is the hard deadline we impose on a slash-command request's
+// context, kept just under Slack's 3-second window so we always have time
+// to write a response instead of letting Slack time us out first.
+const slackBudget = 2800 * time.Millisecond
+
+// sharedHTTPClient is reused across every outbound call (Yelp, Google
+// Places, Foursquare, Slack) instead of constructing a new http.Client per
+// request, so connections to the same host get pooled rather than
+// renegotiated on every invocation.
+var sharedHTTPClient = &http.Client{
+	Transport: &http.Transport{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 20,
+		IdleConnTimeout:     90 * time.Second,
+	},
+}
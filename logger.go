@@ -0,0 +1,13 @@
+package bier
+
+import (
+	"os"
+
+	"github.com/rs/zerolog"
+)
+
+// logger emits structured JSON records instead of the standard log package,
+// so request-level fields (team_id, user_id, category, latency_ms,
+// yelp_status, ...) can be queried from log aggregation rather than grepped
+// out of plain text.
+var logger = zerolog.New(os.Stdout).With().Timestamp().Logger()
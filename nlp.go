@@ -0,0 +1,57 @@
+package bier
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Preposition anchors recognized in free-form /hi5 text, e.g.
+// "pizza with beer near Los Angeles within 10 miles". Anchors are matched
+// case-insensitively and lazily so later anchors terminate earlier captures.
+var (
+	radiusAnchorRe   = regexp.MustCompile(`(?i)\bwithin\s+(\d+(?:\.\d+)?)\s*miles?\b`)
+	locationAnchorRe = regexp.MustCompile(`(?i)\b(?:near|in|around)\s+(.+?)(?:\s+within\b|\s+with\b|\s+for\b|$)`)
+	termAnchorRe     = regexp.MustCompile(`(?i)\bwith\s+(.+?)(?:\s+near\b|\s+in\b|\s+around\b|\s+within\b|\s+for\b|$)`)
+	forAnchorRe      = regexp.MustCompile(`(?i)\bfor\s+(.+?)(?:\s+near\b|\s+in\b|\s+around\b|\s+within\b|\s+with\b|$)`)
+	firstAnchorRe    = regexp.MustCompile(`(?i)\b(near|in|around|within|with|for)\b`)
+)
+
+// isStructuredParams reports whether text looks like the key=value&... form
+// rather than conversational phrasing.
+func isStructuredParams(text string) bool {
+	return strings.Contains(text, "=")
+}
+
+// parseNaturalLanguage tokenizes free-form /hi5 text such as
+// "pizza with beer near Los Angeles within 10 miles" into the same
+// url.Values shape the key=value grammar produces, so both paths can share
+// the validation in parseParams.
+func parseNaturalLanguage(text string) url.Values {
+	q := url.Values{}
+
+	if m := radiusAnchorRe.FindStringSubmatch(text); m != nil {
+		q.Set("radius", m[1])
+	}
+	if m := locationAnchorRe.FindStringSubmatch(text); m != nil {
+		q.Set("location", strings.TrimSpace(m[1]))
+	}
+	if m := termAnchorRe.FindStringSubmatch(text); m != nil {
+		q.Set("term", strings.TrimSpace(m[1]))
+	} else if m := forAnchorRe.FindStringSubmatch(text); m != nil {
+		// "pizza for date night near LA" -- "for" is an alternate way of
+		// phrasing the same term anchor as "with", used when there's no
+		// "with" clause already.
+		q.Set("term", strings.TrimSpace(m[1]))
+	}
+
+	category := text
+	if loc := firstAnchorRe.FindStringIndex(text); loc != nil {
+		category = text[:loc[0]]
+	}
+	if category = strings.TrimSpace(category); category != "" {
+		q.Set("category", category)
+	}
+
+	return q
+}
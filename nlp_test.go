@@ -0,0 +1,67 @@
+package bier
+
+import "testing"
+
+func TestParseNaturalLanguage(t *testing.T) {
+	cases := []struct {
+		name string
+		text string
+		want map[string]string
+	}{
+		{
+			name: "full sentence",
+			text: "pizza with beer near Los Angeles within 10 miles",
+			want: map[string]string{
+				"category": "pizza",
+				"term":     "beer",
+				"location": "Los Angeles",
+				"radius":   "10",
+			},
+		},
+		{
+			name: "for anchor used as term",
+			text: "coffee for date night near Austin",
+			want: map[string]string{
+				"category": "coffee",
+				"term":     "date night",
+				"location": "Austin",
+			},
+		},
+		{
+			name: "with wins over for when both present",
+			text: "coffee with oat milk for date night near Austin",
+			want: map[string]string{
+				"category": "coffee",
+				"term":     "oat milk",
+				"location": "Austin",
+			},
+		},
+		{
+			name: "category only",
+			text: "tacos",
+			want: map[string]string{
+				"category": "tacos",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			q := parseNaturalLanguage(tc.text)
+			for key, want := range tc.want {
+				if got := q.Get(key); got != want {
+					t.Errorf("q.Get(%q) = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestIsStructuredParams(t *testing.T) {
+	if !isStructuredParams("category=pizza&location=90210") {
+		t.Errorf("isStructuredParams(key=value) = false, want true")
+	}
+	if isStructuredParams("pizza near Los Angeles") {
+		t.Errorf("isStructuredParams(free text) = true, want false")
+	}
+}
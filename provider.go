@@ -0,0 +1,301 @@
+package bier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Env vars
+var googlePlacesAPIKey = os.Getenv("GOOGLE_PLACES_API_KEY")
+var foursquareAPIKey = os.Getenv("FOURSQUARE_API_KEY")
+
+// Provider is a business-search backend. Implementations normalize their
+// native response into the shared Business shape so callers don't need to
+// know which upstream API served a request.
+type Provider interface {
+	Search(ctx context.Context, params *Params) ([]Business, error)
+}
+
+// selectProvider resolves a provider by name, falling back to the PROVIDER
+// env var and then Yelp when name is empty.
+func selectProvider(name string) Provider {
+	if name == "" {
+		name = os.Getenv("PROVIDER")
+	}
+	switch strings.ToLower(name) {
+	case "google", "google_places", "googleplaces":
+		return &GooglePlacesProvider{}
+	case "foursquare":
+		return &FoursquareProvider{}
+	default:
+		return &YelpProvider{}
+	}
+}
+
+// providerSupportsPaging reports whether a provider can serve a further page
+// of results for the same search. Yelp's Fusion API takes a plain numeric
+// offset, which is what our "Next 5" button value encodes. Google's Nearby
+// Search API instead requires the next_page_token from the *previous*
+// response, which doesn't exist yet when we render the first page, so it
+// can't be expressed as a re-playable button value -- same for Foursquare,
+// whose v3 Places Search API has no offset/page parameter at all. The "Next
+// 5" button is hidden for those providers rather than wired up to silently
+// re-fetch the same first page.
+func providerSupportsPaging(name string) bool {
+	if name == "" {
+		name = os.Getenv("PROVIDER")
+	}
+	switch strings.ToLower(name) {
+	case "", "yelp":
+		return true
+	default:
+		return false
+	}
+}
+
+// YelpProvider searches Yelp's Fusion API.
+type YelpProvider struct{}
+
+func (p *YelpProvider) Search(ctx context.Context, params *Params) ([]Business, error) {
+	start := time.Now()
+
+	req, err := http.NewRequest("GET", apiBase, bytes.NewBuffer([]byte("")))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", apiKey))
+	req.Header.Add("Content-Type", "application/json")
+
+	q := req.URL.Query()
+	q.Add("location", params.Location)
+	q.Add("radius", fmt.Sprintf("%d", params.Radius))
+	q.Add("categories", params.Category)
+	q.Add("limit", "5")
+	q.Add("offset", fmt.Sprintf("%d", params.Offset))
+	q.Add("sort_by", "rating")
+	if params.SearchTerm != "" {
+		q.Add("term", params.SearchTerm)
+	}
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := sharedHTTPClient.Do(req)
+	if err != nil {
+		logger.Error().Err(err).Str("category", params.Category).Dur("latency_ms", time.Since(start)).Msg("yelp request failed")
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var data YelpResponse
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, err
+	}
+
+	logger.Info().
+		Str("category", params.Category).
+		Str("yelp_status", resp.Status).
+		Dur("latency_ms", time.Since(start)).
+		Msg("yelp request complete")
+
+	return data.Businesses, nil
+}
+
+// googlePlacesResponse is the subset of Google's Nearby Search response we need.
+type googlePlacesResponse struct {
+	Results []struct {
+		Name             string  `json:"name"`
+		Rating           float32 `json:"rating"`
+		UserRatingsTotal int     `json:"user_ratings_total"`
+		PriceLevel       int     `json:"price_level"`
+		Vicinity         string  `json:"vicinity"`
+		PlaceID          string  `json:"place_id"`
+		Geometry         struct {
+			Location struct {
+				Lat float64 `json:"lat"`
+				Lng float64 `json:"lng"`
+			} `json:"location"`
+		} `json:"geometry"`
+		Photos []struct {
+			PhotoReference string `json:"photo_reference"`
+		} `json:"photos"`
+	} `json:"results"`
+}
+
+// GooglePlacesProvider searches the Google Places Nearby Search API.
+type GooglePlacesProvider struct{}
+
+func (p *GooglePlacesProvider) Search(ctx context.Context, params *Params) ([]Business, error) {
+	start := time.Now()
+
+	req, err := http.NewRequest("GET", "https://maps.googleapis.com/maps/api/place/nearbysearch/json", nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+
+	term := params.Category
+	if params.SearchTerm != "" {
+		term = fmt.Sprintf("%s %s", term, params.SearchTerm)
+	}
+
+	q := req.URL.Query()
+	q.Add("key", googlePlacesAPIKey)
+	q.Add("keyword", term)
+	q.Add("address", params.Location)
+	q.Add("radius", fmt.Sprintf("%d", params.Radius))
+	q.Add("rankby", "prominence")
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := sharedHTTPClient.Do(req)
+	if err != nil {
+		logger.Error().Err(err).Str("category", params.Category).Dur("latency_ms", time.Since(start)).Msg("google places request failed")
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var data googlePlacesResponse
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, err
+	}
+
+	logger.Info().
+		Str("category", params.Category).
+		Str("provider_status", resp.Status).
+		Dur("latency_ms", time.Since(start)).
+		Msg("google places request complete")
+
+	// Nearby Search has no limit parameter, so keep the first 5 results to
+	// match the other providers' limit=5.
+	if len(data.Results) > 5 {
+		data.Results = data.Results[:5]
+	}
+
+	businesses := make([]Business, 0, len(data.Results))
+	for _, r := range data.Results {
+		b := Business{
+			Name:        r.Name,
+			Rating:      r.Rating,
+			ReviewCount: r.UserRatingsTotal,
+			Price:       strings.Repeat("$", r.PriceLevel),
+			URL:         fmt.Sprintf("https://www.google.com/maps/place/?q=place_id:%s", r.PlaceID),
+			Lat:         r.Geometry.Location.Lat,
+			Lon:         r.Geometry.Location.Lng,
+		}
+		if len(r.Photos) > 0 {
+			b.ImageURL = fmt.Sprintf(
+				"https://maps.googleapis.com/maps/api/place/photo?maxwidth=400&photoreference=%s&key=%s",
+				r.Photos[0].PhotoReference,
+				googlePlacesAPIKey,
+			)
+		}
+		b.Location.DisplayAddress = []string{r.Vicinity}
+		businesses = append(businesses, b)
+	}
+	return businesses, nil
+}
+
+// foursquareResponse is the subset of Foursquare's Places API v3 response we need.
+type foursquareResponse struct {
+	Results []struct {
+		Name   string  `json:"name"`
+		Rating float32 `json:"rating"`
+		Stats  struct {
+			TotalRatings int `json:"total_ratings"`
+		} `json:"stats"`
+		Price struct {
+			Tier int `json:"tier"`
+		} `json:"price"`
+		Location struct {
+			FormattedAddress string `json:"formatted_address"`
+		} `json:"location"`
+		Geocodes struct {
+			Main struct {
+				Latitude  float64 `json:"latitude"`
+				Longitude float64 `json:"longitude"`
+			} `json:"main"`
+		} `json:"geocodes"`
+		Link string `json:"link"`
+	} `json:"results"`
+}
+
+// FoursquareProvider searches the Foursquare Places API.
+type FoursquareProvider struct{}
+
+func (p *FoursquareProvider) Search(ctx context.Context, params *Params) ([]Business, error) {
+	start := time.Now()
+
+	req, err := http.NewRequest("GET", "https://api.foursquare.com/v3/places/search", nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Add("Authorization", foursquareAPIKey)
+	req.Header.Add("Accept", "application/json")
+
+	q := req.URL.Query()
+	q.Add("query", params.Category)
+	q.Add("near", params.Location)
+	q.Add("radius", fmt.Sprintf("%d", params.Radius))
+	q.Add("limit", "5")
+	q.Add("sort", "RATING")
+	if params.SearchTerm != "" {
+		q.Set("query", fmt.Sprintf("%s %s", params.Category, params.SearchTerm))
+	}
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := sharedHTTPClient.Do(req)
+	if err != nil {
+		logger.Error().Err(err).Str("category", params.Category).Dur("latency_ms", time.Since(start)).Msg("foursquare request failed")
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var data foursquareResponse
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, err
+	}
+
+	logger.Info().
+		Str("category", params.Category).
+		Str("provider_status", resp.Status).
+		Dur("latency_ms", time.Since(start)).
+		Msg("foursquare request complete")
+
+	businesses := make([]Business, 0, len(data.Results))
+	for _, r := range data.Results {
+		b := Business{
+			Name:        r.Name,
+			Rating:      r.Rating,
+			ReviewCount: r.Stats.TotalRatings,
+			Price:       strings.Repeat("$", r.Price.Tier),
+			URL:         r.Link,
+			Lat:         r.Geocodes.Main.Latitude,
+			Lon:         r.Geocodes.Main.Longitude,
+		}
+		b.Location.DisplayAddress = []string{r.Location.FormattedAddress}
+		businesses = append(businesses, b)
+	}
+	return businesses, nil
+}
@@ -0,0 +1,50 @@
+package bier
+
+import "testing"
+
+func TestSelectProvider(t *testing.T) {
+	cases := []struct {
+		name string
+		want interface{}
+	}{
+		{"google", &GooglePlacesProvider{}},
+		{"google_places", &GooglePlacesProvider{}},
+		{"googleplaces", &GooglePlacesProvider{}},
+		{"foursquare", &FoursquareProvider{}},
+		{"", &YelpProvider{}},
+		{"yelp", &YelpProvider{}},
+		{"unknown", &YelpProvider{}},
+	}
+
+	for _, tc := range cases {
+		got := selectProvider(tc.name)
+		switch tc.want.(type) {
+		case *GooglePlacesProvider:
+			if _, ok := got.(*GooglePlacesProvider); !ok {
+				t.Errorf("selectProvider(%q) = %T, want *GooglePlacesProvider", tc.name, got)
+			}
+		case *FoursquareProvider:
+			if _, ok := got.(*FoursquareProvider); !ok {
+				t.Errorf("selectProvider(%q) = %T, want *FoursquareProvider", tc.name, got)
+			}
+		case *YelpProvider:
+			if _, ok := got.(*YelpProvider); !ok {
+				t.Errorf("selectProvider(%q) = %T, want *YelpProvider", tc.name, got)
+			}
+		}
+	}
+}
+
+func TestProviderSupportsPaging(t *testing.T) {
+	cases := map[string]bool{
+		"":           true,
+		"yelp":       true,
+		"google":     false,
+		"foursquare": false,
+	}
+	for name, want := range cases {
+		if got := providerSupportsPaging(name); got != want {
+			t.Errorf("providerSupportsPaging(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package bier
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// Env vars
+var slackSigningSecret = os.Getenv("SLACK_SIGNING_SECRET")
+
+// signatureMaxAge is how old a request is allowed to be before we refuse it,
+// per Slack's replay-attack guidance.
+const signatureMaxAge = 5 * time.Minute
+
+// verifySlackSignature validates the X-Slack-Signature header against the
+// raw request body using SLACK_SIGNING_SECRET, replacing the old
+// params.Token == slackToken check that Slack has deprecated.
+func verifySlackSignature(r *http.Request, body []byte) error {
+	timestamp := r.Header.Get("X-Slack-Request-Timestamp")
+	if timestamp == "" {
+		return fmt.Errorf("missing X-Slack-Request-Timestamp header")
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid X-Slack-Request-Timestamp header: %w", err)
+	}
+	if math.Abs(time.Since(time.Unix(ts, 0)).Seconds()) > signatureMaxAge.Seconds() {
+		return fmt.Errorf("request timestamp is too old")
+	}
+
+	sig := r.Header.Get("X-Slack-Signature")
+	if sig == "" {
+		return fmt.Errorf("missing X-Slack-Signature header")
+	}
+
+	base := fmt.Sprintf("v0:%s:%s", timestamp, body)
+	mac := hmac.New(sha256.New, []byte(slackSigningSecret))
+	mac.Write([]byte(base))
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+
+	if !hmac.Equal([]byte(expected), []byte(sig)) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+// buildBusinessBlocks renders the Hi-5 results as Block Kit blocks, including
+// a row of "Show map"/"Reserve" link buttons for each business and, for
+// providers that support paging, a "Next 5" button that the Interactions
+// handler services.
+func buildBusinessBlocks(params *Params, businesses []Business) []slack.Block {
+	msg := fmt.Sprintf("*Ok @%s here's a Hi-5 for %s", params.UserName, params.Category)
+	if params.SearchTerm != "" {
+		msg = fmt.Sprintf("%s and %s", msg, params.SearchTerm)
+	}
+	msg = fmt.Sprintf("%s near %s*", msg, params.Location)
+
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, msg, false, false), nil, nil),
+		slack.NewDividerBlock(),
+	}
+
+	for i, b := range businesses {
+		text := fmt.Sprintf(
+			"*%s %s:* %.1f ⭐ (%d reviews)\n%s\n\n%s",
+			b.Name,
+			b.Price,
+			b.Rating,
+			b.ReviewCount,
+			strings.Join(b.Location.DisplayAddress, " "),
+			b.URL,
+		)
+		blocks = append(blocks,
+			slack.NewSectionBlock(
+				slack.NewTextBlockObject(slack.MarkdownType, text, false, false),
+				nil,
+				slack.NewAccessory(slack.NewImageBlockElement(b.ImageURL, b.Name)),
+			),
+			slack.NewActionBlock(
+				fmt.Sprintf("hi5-actions-%d", i),
+				&slack.ButtonBlockElement{
+					Type:     slack.METButton,
+					ActionID: "show_map",
+					Text:     slack.NewTextBlockObject(slack.PlainTextType, "Show map", false, false),
+					URL:      b.URL,
+				},
+				&slack.ButtonBlockElement{
+					Type:     slack.METButton,
+					ActionID: "reserve",
+					Text:     slack.NewTextBlockObject(slack.PlainTextType, "Reserve", false, false),
+					URL:      b.URL,
+				},
+			),
+		)
+	}
+
+	if providerSupportsPaging(params.Provider) {
+		blocks = append(blocks, slack.NewActionBlock(
+			"hi5-pagination",
+			slack.NewButtonBlockElement("next_5", nextPageValue(params), slack.NewTextBlockObject(slack.PlainTextType, "Next 5", false, false)),
+		))
+	}
+
+	return blocks
+}
+
+// nextPageValue encodes everything paginateResults needs to re-run this
+// search one page further, since a button's value is the only state the
+// Interactions handler gets back.
+func nextPageValue(params *Params) string {
+	q := url.Values{}
+	q.Set("provider", params.Provider)
+	q.Set("category", params.Category)
+	q.Set("location", params.Location)
+	q.Set("term", params.SearchTerm)
+	q.Set("radius", fmt.Sprintf("%d", params.Radius))
+	q.Set("offset", fmt.Sprintf("%d", params.Offset+5))
+	return q.Encode()
+}
+
+// postToSlack delivers a Block Kit message back to the slash command's
+// response_url via an incoming webhook, honoring ctx's deadline and reusing
+// sharedHTTPClient instead of a per-call client.
+func postToSlack(ctx context.Context, responseURL string, blocks []slack.Block) error {
+	start := time.Now()
+
+	msg := &slack.WebhookMessage{
+		ResponseType: "in_channel",
+		Blocks:       &slack.Blocks{BlockSet: blocks},
+	}
+	if err := slack.PostWebhookCustomHTTPContext(ctx, responseURL, sharedHTTPClient, msg); err != nil {
+		logger.Error().Err(err).Dur("latency_ms", time.Since(start)).Msg("failed to post to slack")
+		return err
+	}
+
+	logger.Info().Dur("latency_ms", time.Since(start)).Msg("posted to slack")
+	return nil
+}
+
+func postNotFound(ctx context.Context, params *Params) error {
+	logger.Info().Str("category", params.Category).Msg("no results found")
+
+	msg := fmt.Sprintf(
+		"*Sorry we couldn't find any results for %s in %s. "+
+			"Try increasing your search radius*",
+		params.Category,
+		params.Location,
+	)
+	blocks := []slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, msg, false, false), nil, nil),
+	}
+	return postToSlack(ctx, params.ResponseURL, blocks)
+}
+
+// Interactions services Slack's block_actions payloads. A "Next 5" click
+// re-runs the search one page further; "Show map" and "Reserve" are link
+// buttons that open the business URL in Slack itself, so their payloads are
+// only acknowledged and logged.
+func Interactions(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), slackBudget)
+	defer cancel()
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to read interaction body")
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	if err := verifySlackSignature(r, body); err != nil {
+		logger.Info().Err(err).Msg("rejected interaction")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// r.Body is already drained above, so pull "payload" out of body
+	// directly rather than r.FormValue, which would need to read the body
+	// itself and finds nothing but EOF.
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to parse interaction body")
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	var callback slack.InteractionCallback
+	if err := json.Unmarshal([]byte(form.Get("payload")), &callback); err != nil {
+		logger.Error().Err(err).Msg("failed to decode interaction payload")
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if len(callback.ActionCallback.BlockActions) == 0 {
+		return
+	}
+	action := callback.ActionCallback.BlockActions[0]
+
+	switch action.ActionID {
+	case "next_5":
+		paginateResults(ctx, &callback)
+	default:
+		logger.Info().Str("action_id", action.ActionID).Msg("unhandled block action")
+	}
+}
+
+// paginateResults re-runs the search encoded in the "Next 5" button's value
+// (see nextPageValue) one page further and posts the results back to the
+// channel the interaction came from.
+func paginateResults(ctx context.Context, callback *slack.InteractionCallback) {
+	if len(callback.ActionCallback.BlockActions) == 0 {
+		return
+	}
+	action := callback.ActionCallback.BlockActions[0]
+
+	q, err := url.ParseQuery(action.Value)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to decode pagination state")
+		return
+	}
+
+	radius, _ := strconv.Atoi(q.Get("radius"))
+	offset, _ := strconv.Atoi(q.Get("offset"))
+	params := &Params{
+		ResponseURL: callback.ResponseURL,
+		UserName:    callback.User.Name,
+		Provider:    q.Get("provider"),
+		Category:    q.Get("category"),
+		Location:    q.Get("location"),
+		SearchTerm:  q.Get("term"),
+		Radius:      radius,
+		Offset:      offset,
+	}
+
+	provider := selectProvider(params.Provider)
+	businesses, err := provider.Search(ctx, params)
+	if err != nil {
+		logger.Error().Err(err).Str("category", params.Category).Msg("failed to fetch next page")
+		return
+	}
+	if len(businesses) == 0 {
+		if err := postNotFound(ctx, params); err != nil {
+			logger.Error().Err(err).Msg("failed to post empty next page")
+		}
+		return
+	}
+
+	blocks := buildBusinessBlocks(params, businesses)
+	if err := postToSlack(ctx, params.ResponseURL, blocks); err != nil {
+		logger.Error().Err(err).Msg("failed to post next page")
+	}
+}
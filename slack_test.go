@@ -0,0 +1,93 @@
+package bier
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func signedRequest(t *testing.T, secret string, body []byte, ts time.Time) *http.Request {
+	t.Helper()
+
+	timestamp := strconv.FormatInt(ts.Unix(), 10)
+	base := fmt.Sprintf("v0:%s:%s", timestamp, body)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(base))
+	sig := "v0=" + hex.EncodeToString(mac.Sum(nil))
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("X-Slack-Request-Timestamp", timestamp)
+	req.Header.Set("X-Slack-Signature", sig)
+	return req
+}
+
+func TestVerifySlackSignature(t *testing.T) {
+	slackSigningSecret = "test-secret"
+	body := []byte("text=pizza+near+LA")
+
+	req := signedRequest(t, slackSigningSecret, body, time.Now())
+	if err := verifySlackSignature(req, body); err != nil {
+		t.Fatalf("verifySlackSignature(valid) = %v, want nil", err)
+	}
+}
+
+func TestVerifySlackSignatureRejectsBadSignature(t *testing.T) {
+	slackSigningSecret = "test-secret"
+	body := []byte("text=pizza+near+LA")
+
+	req := signedRequest(t, "wrong-secret", body, time.Now())
+	if err := verifySlackSignature(req, body); err == nil {
+		t.Fatalf("verifySlackSignature(bad signature) = nil, want error")
+	}
+}
+
+func TestVerifySlackSignatureRejectsStaleTimestamp(t *testing.T) {
+	slackSigningSecret = "test-secret"
+	body := []byte("text=pizza+near+LA")
+
+	req := signedRequest(t, slackSigningSecret, body, time.Now().Add(-10*time.Minute))
+	if err := verifySlackSignature(req, body); err == nil {
+		t.Fatalf("verifySlackSignature(stale timestamp) = nil, want error")
+	}
+}
+
+func TestNextPageValueRoundTrips(t *testing.T) {
+	params := &Params{
+		Provider:   "google",
+		Category:   "pizza",
+		Location:   "Los Angeles,CA",
+		SearchTerm: "beer",
+		Radius:     8000,
+		Offset:     5,
+	}
+
+	value := nextPageValue(params)
+	if value == "" {
+		t.Fatalf("nextPageValue returned empty string")
+	}
+
+	q, err := url.ParseQuery(value)
+	if err != nil {
+		t.Fatalf("url.ParseQuery(%q): %v", value, err)
+	}
+	want := map[string]string{
+		"provider": "google",
+		"category": "pizza",
+		"location": "Los Angeles,CA",
+		"term":     "beer",
+		"radius":   "8000",
+		"offset":   "10",
+	}
+	for key, w := range want {
+		if got := q.Get(key); got != w {
+			t.Errorf("q.Get(%q) = %q, want %q", key, got, w)
+		}
+	}
+}
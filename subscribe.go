@@ -0,0 +1,341 @@
+package bier
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	mathrand "math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Env vars
+var firestoreProjectID = os.Getenv("FIRESTORE_PROJECT_ID")
+
+// subscriptionLimitPerChannel caps how many active subscriptions a single
+// channel can have at once, so one noisy channel can't monopolize the Cron
+// sweep. It's a simultaneous-active-count cap, not a rolling daily quota --
+// deleting a subscription immediately frees a slot.
+var subscriptionLimitPerChannel = envInt("SUBSCRIPTION_LIMIT_PER_CHANNEL", 20)
+
+// Subscription is a standing "/hi5 subscribe" request that the Cron handler
+// re-runs on a schedule and posts to a channel's incoming webhook.
+type Subscription struct {
+	ID          string
+	TeamID      string
+	ChannelID   string
+	ResponseURL string
+	Category    string
+	Location    string
+	Term        string
+	Provider    string
+	Radius      int
+	CronExpr    string
+	LastTick    time.Time
+}
+
+// Store persists subscriptions. Production is backed by Firestore; tests
+// and local dev fall back to an in-memory store.
+type Store interface {
+	Create(ctx context.Context, sub *Subscription) error
+	Delete(ctx context.Context, teamID, channelID, id string) error
+	List(ctx context.Context, teamID, channelID string) ([]*Subscription, error)
+	All(ctx context.Context) ([]*Subscription, error)
+	// MarkRun records that a subscription fired for the given tick,
+	// returning false if it was already recorded so Cron retries don't
+	// double-post.
+	MarkRun(ctx context.Context, id string, tick time.Time) (bool, error)
+}
+
+func newStore() Store {
+	if firestoreProjectID != "" {
+		return newFirestoreStore(firestoreProjectID)
+	}
+	return newMemoryStore()
+}
+
+var subscriptionStore = newStore()
+
+// MemoryStore is a Store fallback for local dev and tests.
+type MemoryStore struct {
+	mu   sync.Mutex
+	subs map[string]*Subscription
+	ran  map[string]time.Time
+}
+
+func newMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		subs: make(map[string]*Subscription),
+		ran:  make(map[string]time.Time),
+	}
+}
+
+func subKey(teamID, channelID, id string) string {
+	return fmt.Sprintf("%s/%s/%s", teamID, channelID, id)
+}
+
+func (s *MemoryStore) Create(ctx context.Context, sub *Subscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subs[subKey(sub.TeamID, sub.ChannelID, sub.ID)] = sub
+	return nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, teamID, channelID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := subKey(teamID, channelID, id)
+	if _, ok := s.subs[key]; !ok {
+		return fmt.Errorf("subscription %s not found", id)
+	}
+	delete(s.subs, key)
+	return nil
+}
+
+func (s *MemoryStore) List(ctx context.Context, teamID, channelID string) ([]*Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prefix := subKey(teamID, channelID, "")
+	var subs []*Subscription
+	for key, sub := range s.subs {
+		if strings.HasPrefix(key, prefix) {
+			subs = append(subs, sub)
+		}
+	}
+	return subs, nil
+}
+
+func (s *MemoryStore) All(ctx context.Context) ([]*Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	subs := make([]*Subscription, 0, len(s.subs))
+	for _, sub := range s.subs {
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
+
+func (s *MemoryStore) MarkRun(ctx context.Context, id string, tick time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if last, ok := s.ran[id]; ok && !last.Before(tick) {
+		return false, nil
+	}
+	s.ran[id] = tick
+	return true, nil
+}
+
+// generateSubscriptionID returns a short random id safe to share in Slack
+// messages (e.g. for "/hi5 unsubscribe <id>").
+func generateSubscriptionID() string {
+	b := make([]byte, 5)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// dispatchSubscriptionCommand recognizes the subscribe/unsubscribe/list
+// subcommands in /hi5's text and handles them, returning ok=false if text
+// is an ordinary search instead.
+func dispatchSubscriptionCommand(text, teamID, channelID, responseURL string) (reply string, ok bool) {
+	switch {
+	case strings.HasPrefix(strings.ToLower(text), "subscribe"):
+		rest := strings.TrimSpace(text[len("subscribe"):])
+		return handleSubscribe(rest, teamID, channelID, responseURL), true
+	case strings.HasPrefix(strings.ToLower(text), "unsubscribe"):
+		rest := strings.TrimSpace(text[len("unsubscribe"):])
+		return handleUnsubscribe(rest, teamID, channelID), true
+	case strings.ToLower(text) == "list":
+		return handleListSubscriptions(teamID, channelID), true
+	}
+	return "", false
+}
+
+// handleSubscribe parses `/hi5 subscribe category=coffee&location=90210&cron=0 9 * * MON`
+// and stores the resulting Subscription.
+func handleSubscribe(rest, teamID, channelID, responseURL string) string {
+	q, err := url.ParseQuery(rest)
+	if err != nil {
+		return "Sorry, I couldn't parse that subscription. Try `/hi5 help`"
+	}
+
+	category := strings.ToLower(q.Get("category"))
+	location := q.Get("location")
+	cronExpr := q.Get("cron")
+
+	if category == "" || location == "" || cronExpr == "" {
+		return "Usage: /hi5 subscribe category=<category>&location=<city,state|zip>&cron=<cron expression>"
+	}
+
+	if _, err := cron.ParseStandard(cronExpr); err != nil {
+		return fmt.Sprintf("That doesn't look like a valid cron expression: %s", err.Error())
+	}
+
+	ctx := context.Background()
+	existing, err := subscriptionStore.List(ctx, teamID, channelID)
+	if err == nil && len(existing) >= subscriptionLimitPerChannel {
+		return fmt.Sprintf("This channel has reached its limit of %d subscriptions", subscriptionLimitPerChannel)
+	}
+
+	radiusMi := 5.0
+	if q.Get("radius") != "" {
+		if rad, err := strconv.ParseFloat(q.Get("radius"), 64); err == nil {
+			radiusMi = rad
+		}
+	}
+
+	sub := &Subscription{
+		ID:          generateSubscriptionID(),
+		TeamID:      teamID,
+		ChannelID:   channelID,
+		ResponseURL: responseURL,
+		Category:    category,
+		Location:    location,
+		Term:        q.Get("term"),
+		Provider:    strings.ToLower(q.Get("provider")),
+		Radius:      milesToMeters(radiusMi),
+		CronExpr:    cronExpr,
+	}
+
+	if err := subscriptionStore.Create(ctx, sub); err != nil {
+		logger.Error().Err(err).Msg("failed to create subscription")
+		return "Sorry, something went wrong creating that subscription"
+	}
+
+	return fmt.Sprintf(
+		"*Subscribed!* You'll get a Hi-5 for %s near %s on `%s` (id: `%s`)",
+		category, location, cronExpr, sub.ID,
+	)
+}
+
+// handleUnsubscribe parses `/hi5 unsubscribe <id>`.
+func handleUnsubscribe(id, teamID, channelID string) string {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return "Usage: /hi5 unsubscribe <id>"
+	}
+	if err := subscriptionStore.Delete(context.Background(), teamID, channelID, id); err != nil {
+		return fmt.Sprintf("Couldn't find a subscription with id `%s`", id)
+	}
+	return fmt.Sprintf("Unsubscribed `%s`", id)
+}
+
+// handleListSubscriptions parses `/hi5 list`.
+func handleListSubscriptions(teamID, channelID string) string {
+	subs, err := subscriptionStore.List(context.Background(), teamID, channelID)
+	if err != nil {
+		return "Sorry, something went wrong listing subscriptions"
+	}
+	if len(subs) == 0 {
+		return "No active subscriptions in this channel. Create one with `/hi5 subscribe`"
+	}
+
+	var b strings.Builder
+	b.WriteString("*Active subscriptions:*\n")
+	for _, sub := range subs {
+		fmt.Fprintf(&b, "`%s`: %s near %s on `%s`\n", sub.ID, sub.Category, sub.Location, sub.CronExpr)
+	}
+	return b.String()
+}
+
+// Cron is invoked on a fixed interval (e.g. by Cloud Scheduler) and delivers
+// a fresh Hi-5 for every subscription that's due. Deliveries run concurrently
+// but Cron waits for all of them before responding, since a Cloud Functions
+// instance can be frozen the moment the response is flushed -- an unwaited
+// goroutine could be killed mid-delivery.
+func Cron(w http.ResponseWriter, r *http.Request) {
+	logger.Info().Msg("cron tick received")
+
+	ctx := r.Context()
+	subs, err := subscriptionStore.All(ctx)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to list subscriptions")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	var wg sync.WaitGroup
+	for _, sub := range subs {
+		schedule, err := cron.ParseStandard(sub.CronExpr)
+		if err != nil {
+			logger.Error().Err(err).Str("subscription_id", sub.ID).Msg("subscription has invalid cron expression")
+			continue
+		}
+
+		// A tick is due if the schedule's next run from one minute ago has
+		// already passed -- i.e. it landed within the last minute.
+		tick := schedule.Next(now.Add(-time.Minute))
+		if tick.After(now) {
+			continue
+		}
+
+		wg.Add(1)
+		go func(sub *Subscription, tick time.Time) {
+			defer wg.Done()
+			deliverSubscription(ctx, sub, tick)
+		}(sub, tick)
+	}
+	wg.Wait()
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// deliverSubscription re-runs a subscription's search and posts the results
+// to its channel. A small random jitter spreads out subscriptions that share
+// the same tick instead of bursting them all at once. MarkRun claims the
+// tick before the search runs, so a retried Cron call for the same tick
+// skips the subscription instead of posting it twice; a delivery that fails
+// after its tick is claimed is not attempted again for that tick.
+func deliverSubscription(ctx context.Context, sub *Subscription, tick time.Time) {
+	jitter := time.Duration(mathrand.Intn(30)) * time.Second
+	time.Sleep(jitter)
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if ok, err := subscriptionStore.MarkRun(ctx, sub.ID, tick); err != nil {
+		logger.Error().Err(err).Str("subscription_id", sub.ID).Msg("failed to record tick for subscription")
+		return
+	} else if !ok {
+		logger.Info().Str("subscription_id", sub.ID).Msg("tick already delivered by an earlier or concurrent run")
+		return
+	}
+
+	params := &Params{
+		ResponseURL: sub.ResponseURL,
+		Location:    sub.Location,
+		Radius:      sub.Radius,
+		Category:    sub.Category,
+		SearchTerm:  sub.Term,
+		Provider:    sub.Provider,
+		UserName:    "hi5",
+	}
+
+	provider := selectProvider(sub.Provider)
+	businesses, err := provider.Search(ctx, params)
+	if err != nil {
+		logger.Error().Err(err).Str("subscription_id", sub.ID).Msg("subscription failed to fetch results")
+		return
+	}
+
+	if len(businesses) == 0 {
+		if err := postNotFound(ctx, params); err != nil {
+			logger.Error().Err(err).Str("subscription_id", sub.ID).Msg("subscription failed to post empty results")
+			return
+		}
+	} else {
+		blocks := buildBusinessBlocks(params, businesses)
+		if err := postToSlack(ctx, params.ResponseURL, blocks); err != nil {
+			logger.Error().Err(err).Str("subscription_id", sub.ID).Msg("subscription failed to post results")
+			return
+		}
+	}
+}
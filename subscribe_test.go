@@ -0,0 +1,93 @@
+package bier
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreCreateListDelete(t *testing.T) {
+	s := newMemoryStore()
+	ctx := context.Background()
+
+	sub := &Subscription{ID: "abc123", TeamID: "T1", ChannelID: "C1", Category: "pizza"}
+	if err := s.Create(ctx, sub); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	subs, err := s.List(ctx, "T1", "C1")
+	if err != nil || len(subs) != 1 {
+		t.Fatalf("List = %d subs, err=%v, want 1 sub, err=nil", len(subs), err)
+	}
+
+	if _, err := s.List(ctx, "T1", "C2"); err != nil {
+		t.Fatalf("List(other channel): %v", err)
+	}
+	if subs, _ := s.List(ctx, "T1", "C2"); len(subs) != 0 {
+		t.Fatalf("List(other channel) = %d subs, want 0", len(subs))
+	}
+
+	if err := s.Delete(ctx, "T1", "C1", "abc123"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := s.Delete(ctx, "T1", "C1", "abc123"); err == nil {
+		t.Fatalf("Delete of already-removed subscription = nil error, want error")
+	}
+}
+
+func TestMemoryStoreMarkRun(t *testing.T) {
+	s := newMemoryStore()
+	ctx := context.Background()
+	tick := time.Now()
+
+	first, err := s.MarkRun(ctx, "sub1", tick)
+	if err != nil || !first {
+		t.Fatalf("first MarkRun = ok=%v err=%v, want ok=true err=nil", first, err)
+	}
+
+	second, err := s.MarkRun(ctx, "sub1", tick)
+	if err != nil || second {
+		t.Fatalf("repeat MarkRun for same tick = ok=%v err=%v, want ok=false err=nil", second, err)
+	}
+
+	later, err := s.MarkRun(ctx, "sub1", tick.Add(time.Hour))
+	if err != nil || !later {
+		t.Fatalf("MarkRun for later tick = ok=%v err=%v, want ok=true err=nil", later, err)
+	}
+}
+
+func TestDispatchSubscriptionCommand(t *testing.T) {
+	subscriptionStore = newMemoryStore()
+
+	if _, ok := dispatchSubscriptionCommand("pizza near LA", "T1", "C1", ""); ok {
+		t.Fatalf("dispatchSubscriptionCommand(ordinary search) ok = true, want false")
+	}
+
+	reply, ok := dispatchSubscriptionCommand("subscribe category=pizza&location=90210&cron=0 9 * * MON", "T1", "C1", "")
+	if !ok {
+		t.Fatalf("dispatchSubscriptionCommand(subscribe) ok = false, want true")
+	}
+	if reply == "" {
+		t.Fatalf("subscribe reply is empty")
+	}
+
+	reply, ok = dispatchSubscriptionCommand("list", "T1", "C1", "")
+	if !ok || reply == "" {
+		t.Fatalf("dispatchSubscriptionCommand(list) = %q, ok=%v, want non-empty reply and ok=true", reply, ok)
+	}
+}
+
+func TestHandleSubscribeRejectsBadCron(t *testing.T) {
+	subscriptionStore = newMemoryStore()
+	reply := handleSubscribe("category=pizza&location=90210&cron=not-a-cron", "T1", "C1", "")
+	if reply == "" {
+		t.Fatalf("handleSubscribe with bad cron returned empty reply")
+	}
+	if !strings.HasPrefix(reply, "That doesn't look like a valid cron expression") {
+		t.Fatalf("handleSubscribe with bad cron = %q, want a cron rejection message", reply)
+	}
+	if subs, _ := subscriptionStore.List(context.Background(), "T1", "C1"); len(subs) != 0 {
+		t.Fatalf("handleSubscribe with bad cron stored %d subscriptions, want 0", len(subs))
+	}
+}
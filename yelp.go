@@ -1,59 +1,43 @@
 package bier
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"net/url"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Params struct {
-	Token       string
 	ResponseURL string
 	Location    string
 	Radius      int
+	Offset      int
+	UserID      string
 	UserName    string
 	Category    string
 	SearchTerm  string
+	Provider    string
+	NoCache     bool
 	Help        bool
 }
 
-type TextBlock struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
-}
-
-type AccessoryBlock struct {
-	Type     string `json:"type"`
-	ImageURL string `json:"image_url"`
-	AltText  string `json:"alt_text"`
-}
-
-type Block struct {
-	Type      string          `json:"type"`
-	Text      *TextBlock      `json:"text,omitempty"`
-	Accessory *AccessoryBlock `json:"accessory,omitempty"`
-}
-
-type SlackMessage struct {
-	ResponseType string  `json:"response_type"`
-	Blocks       []Block `json:"blocks"`
-}
-
+// Business is the normalized search result shared by every Provider
+// implementation, regardless of which upstream API produced it.
 type Business struct {
-	Name        string `json:"name"`
-	ImageURL    string `json:"image_url"`
-	URL         string `json:"url"`
-	ReviewCount int    `json:"review_count"`
-	Price       string `json:"price"`
-	Rating      float32
+	Name        string  `json:"name"`
+	ImageURL    string  `json:"image_url"`
+	URL         string  `json:"url"`
+	ReviewCount int     `json:"review_count"`
+	Price       string  `json:"price"`
+	Rating      float32 `json:"rating"`
+	Lat         float64 `json:"lat"`
+	Lon         float64 `json:"lon"`
 	Location    struct {
 		DisplayAddress []string `json:"display_address"`
 	} `json:"location"`
@@ -70,158 +54,61 @@ const slackOrigin = "hooks.slack.com"
 const apiBase = "https://api.yelp.com/v3/businesses/search"
 
 // Env vars
-var slackToken = os.Getenv("SLACK_TOKEN")
 var apiKey = os.Getenv("API_KEY")
 
-func postToSlack(url string, blocks []Block) error {
-	log.Println("Posting message to slack")
-
-	body := SlackMessage{
-		ResponseType: "in_channel",
-		Blocks:       blocks,
-	}
-	data, err := json.Marshal(body)
-	if err != nil {
-		fmt.Printf("Failed to marshal json: %s", err.Error())
-		return err
-	}
-
-	req, _ := http.NewRequest("POST", url, bytes.NewBuffer(data))
-	req.Header.Add("Content-Type", "application/json")
-
-	client := http.Client{}
-	_, err = client.Do(req)
-	if err != nil {
-		fmt.Printf("Failed to post to slack: %s", err.Error())
-		return err
-	}
-	return nil
-}
-
-func buildBusinessBlocks(params *Params, businesses []Business) []Block {
-	log.Println("Building business blocks")
-
-	msg := fmt.Sprintf("*Ok @%s here's a Hi-5 for %s", params.UserName, params.Category)
-	if params.SearchTerm != "" {
-		msg = fmt.Sprintf("%s and %s", msg, params.SearchTerm)
-	}
-	msg = fmt.Sprintf("%s near %s*", msg, params.Location)
-	blocks := []Block{
-		Block{
-			Type: "section",
-			Text: &TextBlock{"mrkdwn", msg},
-		},
-		Block{
-			Type: "divider",
-		},
-	}
-	for _, b := range businesses {
-		text := fmt.Sprintf(
-			"*%s %s:* %.1f ⭐ (%d reviews)\n%s\n\n%s",
-			b.Name,
-			b.Price,
-			b.Rating,
-			b.ReviewCount,
-			strings.Join(b.Location.DisplayAddress, " "),
-			b.URL,
-		)
-		blocks = append(blocks,
-			Block{
-				Type:      "section",
-				Text:      &TextBlock{"mrkdwn", text},
-				Accessory: &AccessoryBlock{"image", b.ImageURL, "alt text"},
-			},
-		)
-	}
-	return blocks
-}
-
-func postNotFound(params *Params) error {
-	log.Printf("Did not find any results for %s", params.Category)
-
-	msg := fmt.Sprintf(
-		"*Sorry we couldn't find any results for %s in %s. "+
-			"Try increasing your search radius*",
-		params.Category,
-		params.Location,
-	)
-	blocks := []Block{
-		{
-			Type: "section",
-			Text: &TextBlock{
-				Type: "mrkdwn",
-				Text: msg,
-			},
-		},
-	}
-	return postToSlack(params.ResponseURL, blocks)
-}
-
-func getYelpResults(params *Params) ([]Business, error) {
-	log.Println("Calling yelp api")
-
-	yelpReq, _ := http.NewRequest("GET", apiBase, bytes.NewBuffer([]byte("")))
-	yelpReq.Header.Add("Authorization", fmt.Sprintf("Bearer %s", apiKey))
-	yelpReq.Header.Add("Content-Type", "application/json")
-
-	q := yelpReq.URL.Query()
-	q.Add("location", params.Location)
-	q.Add("radius", fmt.Sprintf("%d", params.Radius))
-	q.Add("categories", params.Category)
-	q.Add("limit", "5")
-	q.Add("sort_by", "rating")
-	if params.SearchTerm != "" {
-		q.Add("term", params.SearchTerm)
-	}
-	yelpReq.URL.RawQuery = q.Encode()
-
-	client := http.Client{}
-	resp, err := client.Do(yelpReq)
-	if err != nil {
-		return nil, err
-	}
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var data YelpResponse
-	if err := json.Unmarshal(body, &data); err != nil {
-		return nil, err
-	}
-	return data.Businesses, nil
-}
+// resultCache and rateLimiter are injected as interfaces so tests can supply
+// fakes; in production they're backed by Redis/in-memory and an in-memory
+// token bucket respectively.
+var resultCache Cache = newCache()
+var rateLimiter Limiter = newLimiter()
 
 func printHelp(w http.ResponseWriter) {
 	heading := "*Hi5 helps you find the top 5 rated businesses in a specified category and location.*\nYou can find the list of supported categories here: https://www.yelp.com/developers/documentation/v3/all_category_list"
 	usage := `Usage: /hi5 category=<category>&location=<city,state|zip>&[options]
+   or: /hi5 <category> [with <term>] near <location> [within <radius> miles]
 
 Options: key=value
-term:   additional search term to narrow your category results
-radius: radius in miles for the search area (maximum is 24)
+term:     additional search term to narrow your category results
+radius:   radius in miles for the search area (maximum is 24)
+provider: business search provider to use: yelp (default), google, foursquare
+no-cache: set to "true" to force a fresh lookup instead of a cached result
 
-Example: Find top 5 rated pizza places in Los Angeles that serve beer
+Examples: Find top 5 rated pizza places in Los Angeles that serve beer
 /hi5 category=pizza&location=los angeles,ca&term=beer&radius=10
+/hi5 pizza with beer near Los Angeles within 10 miles
+
+Subscriptions: get a recurring Hi-5 posted to this channel
+/hi5 subscribe category=<category>&location=<city,state|zip>&cron=<cron expression>
+/hi5 unsubscribe <id>
+/hi5 list
 `
 	msg := fmt.Sprintf("%s\n\n```\n%s\n```", heading, usage)
 	w.Write([]byte(msg))
 }
 
 func parseParams(params url.Values) (*Params, error) {
-	log.Println("Parsing params")
-
-	token := params.Get("token")
 	responseURL := params.Get("response_url")
+	userID := params.Get("user_id")
 	userName := params.Get("user_name")
 	text := strings.TrimSpace(params.Get("text"))
 
 	if strings.ToLower(text) == "help" {
-		return &Params{Help: true, Token: token}, nil
+		return &Params{Help: true}, nil
 	}
 
-	q, err := url.ParseQuery(text)
-	if err != nil {
-		return nil, err
+	var q url.Values
+	if isStructuredParams(text) {
+		parsed, err := url.ParseQuery(text)
+		if err != nil {
+			return nil, err
+		}
+		q = parsed
+	} else {
+		q = parseNaturalLanguage(text)
+	}
+
+	if q.Get("location") == "" && q.Get("category") == "" {
+		return nil, errors.New("Sorry, I couldn't understand that. Try `/hi5 help` for usage")
 	}
 
 	radiusMi := 5.0
@@ -245,21 +132,27 @@ func parseParams(params url.Values) (*Params, error) {
 		return nil, errors.New("You must specify a category")
 	}
 
-	//convert miles to meters
-	radius := int(radiusMi / 0.00062137)
 	return &Params{
-		Token: token,
 		ResponseURL: responseURL,
-		UserName: userName,
-		Location: q.Get("location"),
-		Category: strings.ToLower(q.Get("category")),
-		SearchTerm: q.Get("term"),
-		Radius: radius,
+		UserID:      userID,
+		UserName:    userName,
+		Location:    q.Get("location"),
+		Category:    strings.ToLower(q.Get("category")),
+		SearchTerm:  q.Get("term"),
+		Provider:    strings.ToLower(q.Get("provider")),
+		NoCache:     q.Get("no-cache") == "true",
+		Radius:      milesToMeters(radiusMi),
 	}, nil
 }
 
+// milesToMeters converts a search radius expressed in miles (as the slash
+// command does) to the meters Yelp and the other providers expect.
+func milesToMeters(mi float64) int {
+	return int(mi / 0.00062137)
+}
+
 func Yelp(w http.ResponseWriter, r *http.Request) {
-	log.Println("Request received")
+	start := time.Now()
 
 	// Set CORS headers for the preflight request
 	if r.Method == http.MethodOptions {
@@ -272,20 +165,45 @@ func Yelp(w http.ResponseWriter, r *http.Request) {
 	// Set main request headers.
 	w.Header().Set("Access-Control-Allow-Origin", slackOrigin)
 
+	// Give ourselves a hard deadline just under Slack's 3-second slash-command
+	// budget so a slow upstream can't make us miss it outright.
+	ctx, cancel := context.WithTimeout(r.Context(), slackBudget)
+	defer cancel()
+
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		log.Println("Failed to read request body")
+		logger.Error().Err(err).Msg("failed to read request body")
 		http.Error(w, "Bad request", http.StatusBadRequest)
 		return
 	}
 
+	if err := verifySlackSignature(r, body); err != nil {
+		logger.Info().Err(err).Msg("rejected request")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
 	bodyValues, err := url.ParseQuery(fmt.Sprintf("%s", body))
 	if err != nil {
-		log.Println("Failed to decode body query string")
+		logger.Error().Err(err).Msg("failed to decode body query string")
 		http.Error(w, "Bad request", http.StatusBadRequest)
 		return
 	}
 
+	text := strings.TrimSpace(bodyValues.Get("text"))
+	teamID := bodyValues.Get("team_id")
+	channelID := bodyValues.Get("channel_id")
+	userID := bodyValues.Get("user_id")
+	responseURL := bodyValues.Get("response_url")
+
+	log := logger.With().Str("team_id", teamID).Str("user_id", userID).Logger()
+
+	if reply, ok := dispatchSubscriptionCommand(text, teamID, channelID, responseURL); ok {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(reply))
+		return
+	}
+
 	params, err := parseParams(bodyValues)
 	if err != nil {
 		w.WriteHeader(http.StatusOK)
@@ -293,37 +211,67 @@ func Yelp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if params.Token != slackToken {
-		log.Println("Unauthorized request")
+	if params.Help {
+		w.WriteHeader(http.StatusOK)
+		printHelp(w)
+		return
+	}
+
+	allowed, err := rateLimiter.Allow(ctx, params.UserID)
+	if err != nil {
+		log.Error().Err(err).Msg("rate limiter error")
+	} else if !allowed {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(fmt.Sprintf("*Slow down @%s!* You've hit the hourly limit for /hi5. Try again in a bit.", params.UserName)))
 		return
 	}
 
 	// Immediately let slack know we have a valid request
 	w.WriteHeader(http.StatusOK)
 
-	if params.Help {
-		printHelp(w)
-		return
+	key := cacheKey(params.Provider, params.Category, params.Location, params.Radius, params.SearchTerm)
+
+	var businesses []Business
+	cached := false
+	if !params.NoCache {
+		if cachedBusinesses, hit, err := resultCache.Get(ctx, key); err != nil {
+			log.Error().Err(err).Msg("cache lookup failed")
+		} else if hit {
+			businesses = cachedBusinesses
+			cached = true
+		}
 	}
 
-	businesses, err := getYelpResults(params)
-	if err != nil {
-		log.Printf("Error getting %s data: %s", params.Category, err.Error())
-		w.Write([]byte("Internal Server Error"))
-		return
+	if !cached {
+		provider := selectProvider(params.Provider)
+		results, err := provider.Search(ctx, params)
+		if err != nil {
+			log.Error().Err(err).Str("category", params.Category).Dur("latency_ms", time.Since(start)).Msg("error getting provider results")
+			w.Write([]byte("Internal Server Error"))
+			return
+		}
+		businesses = results
+		if err := resultCache.Set(ctx, key, businesses, defaultCacheTTL); err != nil {
+			log.Error().Err(err).Msg("failed to cache results")
+		}
 	}
 	if len(businesses) == 0 {
-		if err := postNotFound(params); err != nil {
-			log.Printf("Failed to send empty list message to slack: %s", err.Error())
+		if err := postNotFound(ctx, params); err != nil {
+			log.Error().Err(err).Msg("failed to send empty list message to slack")
 			w.Write([]byte("Internal Server Error"))
 		}
 		return
 	}
 
 	blocks := buildBusinessBlocks(params, businesses)
-	if err := postToSlack(params.ResponseURL, blocks); err != nil {
-		log.Printf("Failed to post %s results to slack: %s", params.Category, err.Error())
+	if err := postToSlack(ctx, params.ResponseURL, blocks); err != nil {
+		log.Error().Err(err).Msg("failed to post results to slack")
 		w.Write([]byte("Internal Server Error"))
 		return
 	}
+
+	log.Info().
+		Str("category", params.Category).
+		Dur("latency_ms", time.Since(start)).
+		Msg("request complete")
 }